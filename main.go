@@ -22,7 +22,9 @@ func (d debugFlag) BeforeApply(ctx *kong.Context) error { //nolint:unparam // Be
 }
 
 type cli struct {
-	Migrate migrate.Cmd `cmd:"" help:"Migrate Crossplane Claims to a new namespace."`
+	Migrate   migrate.Cmd          `cmd:"" help:"Migrate Crossplane Claims to a new namespace."`
+	Plan      migrate.PlanCmd      `cmd:"" name:"plan" help:"Render a migration as a portable plan file instead of running it."`
+	ApplyPlan migrate.ApplyPlanCmd `cmd:"" name:"apply-plan" help:"Apply a migration plan file produced by 'plan'."`
 
 	Debug debugFlag `short:"d" optional:"" help:"(Optional) Verbose logging."`
 }
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+const (
+	errReadFromFile      = "cannot read --from-file"
+	errParseFromFile     = "cannot parse --from-file, expected a YAML or JSON list of {claim, namespace, destNamespace, name} entries"
+	errListBySelector    = "cannot list Claims matching --selector"
+	errMutuallyExclusive = "--selector and --from-file are mutually exclusive"
+)
+
+// itemsFromFile reads a YAML or JSON list of migration entries from path.
+func itemsFromFile(path string) ([]item, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // path is an operator-provided CLI flag.
+	if err != nil {
+		return nil, errors.Wrap(err, errReadFromFile)
+	}
+
+	var items []item
+	if err := yaml.Unmarshal(b, &items); err != nil {
+		return nil, errors.Wrap(err, errParseFromFile)
+	}
+	return items, nil
+}
+
+// itemsFromSelector lists every Claim of the given kind in namespace matching
+// selector, using the dynamic client.
+func itemsFromSelector(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, claimArg, namespace, destNamespace, selector string) ([]item, error) {
+	list, err := dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, errListBySelector)
+	}
+
+	items := make([]item, 0, len(list.Items))
+	for _, obj := range list.Items {
+		items = append(items, itemFrom(claimArg, namespace, destNamespace, obj))
+	}
+	return items, nil
+}
+
+func itemFrom(claimArg, namespace, destNamespace string, obj unstructured.Unstructured) item {
+	return item{
+		Claim:         claimArg,
+		Namespace:     namespace,
+		DestNamespace: destNamespace,
+		Name:          obj.GetName(),
+	}
+}
+
+// migrateBatch migrates every item, running up to c.MaxConcurrency migrations
+// in parallel. A failure on one item does not stop the others; errors are
+// aggregated and a summary is logged once every item has been attempted.
+func (c *Cmd) migrateBatch(ctx context.Context, logger logging.Logger, cluster *clusterPair, items []item) error {
+	maxConcurrency := c.MaxConcurrency
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	sem := make(chan struct{}, maxConcurrency)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+
+	for _, it := range items {
+		it := it
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := c.migrateItem(ctx, logger, cluster, it); err != nil {
+				logger.Info("❌ migration failed", "claim", it.Claim, "name", it.Name, "namespace", it.Namespace, "error", err.Error())
+				mu.Lock()
+				failed = append(failed, fmt.Sprintf("%s/%s.%s: %v", it.Namespace, it.Name, it.Claim, err))
+				mu.Unlock()
+				return
+			}
+			logger.Info("✅ migration succeeded", "claim", it.Claim, "name", it.Name, "namespace", it.Namespace)
+		}()
+	}
+	wg.Wait()
+
+	logger.Info("batch migration complete", "total", len(items), "succeeded", len(items)-len(failed), "failed", len(failed))
+	if len(failed) > 0 {
+		return errors.Errorf("%d of %d Claims failed to migrate: %s", len(failed), len(items), strings.Join(failed, "; "))
+	}
+	return nil
+}
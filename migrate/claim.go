@@ -2,10 +2,14 @@ package migrate
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
 
 	"github.com/crossplane/crossplane-runtime/pkg/errors"
 	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
@@ -13,14 +17,11 @@ import (
 	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
 
 	"github.com/crossplane-contrib/claim-migrator/resource"
-
-	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 const (
 	errCreateDestClaim        = "cannot create destination claim "
 	errGetResource            = "cannot get requested resource"
-	errKubeConfig             = "failed to get kubeconfig"
 	errGetMapping             = "cannot get mapping for resource"
 	errMissingName            = "missing name, must be provided separately 'TYPE[.VERSION][.GROUP] [NAME]' or in the 'TYPE[.VERSION][.GROUP][/NAME]' format"
 	errNameDoubled            = "name provided twice, must be provided separately 'TYPE[.VERSION][.GROUP] [NAME]' or in the 'TYPE[.VERSION][.GROUP][/NAME]' format"
@@ -29,13 +30,40 @@ const (
 	errInvalidResourceAndName = "invalid resource and name"
 	errRestMapper             = "unable to create REST Mapper"
 	errTargetNamespace        = "target namespace does not exist "
+	errSrcKubeConfig          = "failed to load source cluster kubeconfig"
+	errDstKubeConfig          = "failed to load destination cluster kubeconfig"
+	errDstCRDMissing          = "destination cluster does not have a matching CRD for this Claim kind"
 )
 
 type Cmd struct {
-	Claim         string `arg:"" help:"Kind of the Crossplane Claim, accepts the 'TYPE[.VERSION][.GROUP][/NAME]' format."`
-	Namespace     string `short:"n" name:"namespace" help:"Namespace of the existing Claim." default:"default"`
-	DestNamespace string `help:"Destination namespace for the Claim."`
+	Claim         string `arg:"" optional:"" help:"Kind of the Crossplane Claim, accepts the 'TYPE[.VERSION][.GROUP][/NAME]' format."`
+	Namespace     string `short:"n" name:"namespace" help:"Namespace of the existing Claim(s)." default:"default"`
+	DestNamespace string `help:"Destination namespace for the Claim(s)."`
 	Name          string `arg:"" optional:"" help:"(Optional) Name of the Crossplane Claim, can be passed as part of the <claim> claim.example.com/name."`
+
+	DryRun bool `help:"Walk through the migration and print the actions that would be taken, without making any changes."`
+	Diff   bool `help:"Used with --dry-run, print a unified diff of every object that would be changed."`
+
+	Selector       string `help:"Migrate every Claim of the given kind in Namespace matching this label selector, instead of a single named Claim."`
+	FromFile       string `help:"Path to a YAML or JSON file listing multiple Claims to migrate, as an alternative to Claim/--selector. Each entry is a {claim, namespace, destNamespace, name} object."`
+	MaxConcurrency int    `help:"Maximum number of Claims to migrate at once when migrating more than one." default:"1"`
+
+	SrcKubeconfig string `help:"Path to the kubeconfig for the source cluster. Defaults to the ambient kubeconfig."`
+	DstKubeconfig string `help:"Path to the kubeconfig for the destination cluster. Defaults to --src-kubeconfig."`
+	SrcContext    string `help:"kubeconfig context to use for the source cluster."`
+	DstContext    string `help:"kubeconfig context to use for the destination cluster. Defaults to --src-context."`
+	AdoptXR       string `help:"How to handle the Composite when migrating across clusters. 'recreate' (default) leaves the XR in the source cluster and creates a new XR/composed resource tree in the destination. 'import' re-creates the referenced XR in the destination cluster with the same claimRef, so the existing composed resources are adopted rather than re-provisioned." enum:"recreate,import" default:"recreate"`
+
+	NoRollback bool `help:"Do not automatically undo already-applied steps if the migration fails partway through. Leaves the cluster in the failure state for inspection."`
+}
+
+// item is a single Claim to migrate, resolved from either the positional
+// Claim/Name arguments, a --selector listing, or a --from-file entry.
+type item struct {
+	Claim         string `json:"claim"`
+	Namespace     string `json:"namespace"`
+	DestNamespace string `json:"destNamespace"`
+	Name          string `json:"name"`
 }
 
 // Migrate Claim Procedure
@@ -51,116 +79,331 @@ type Cmd struct {
 
 func (c *Cmd) Run(logger logging.Logger) error {
 	ctx := context.Background()
-	logger = logger.WithValues("Resource", c.Claim, "Name", c.Name, "SrcNamespace", c.Namespace, "DestNamespace", c.DestNamespace)
 
-	kubeconfig, err := ctrl.GetConfig()
+	srcConfig, err := resource.LoadConfig(c.SrcKubeconfig, c.SrcContext)
 	if err != nil {
-		return errors.Wrap(err, errKubeConfig)
+		return errors.Wrap(err, errSrcKubeConfig)
 	}
-	logger.Debug("✅ kubeconfig loaded")
+	logger.Debug("✅ source kubeconfig loaded")
 
-	// Client for dealing with CRDs
-	dynamicClient, err := resource.NewDynamicClient(kubeconfig)
+	dstConfig := srcConfig
+	if c.DstKubeconfig != "" || c.DstContext != "" {
+		dstKubeconfig, dstContext := c.DstKubeconfig, c.DstContext
+		if dstKubeconfig == "" {
+			dstKubeconfig = c.SrcKubeconfig
+		}
+		if dstContext == "" {
+			dstContext = c.SrcContext
+		}
+		dstConfig, err = resource.LoadConfig(dstKubeconfig, dstContext)
+		if err != nil {
+			return errors.Wrap(err, errDstKubeConfig)
+		}
+		logger.Debug("✅ destination kubeconfig loaded")
+	}
+
+	cluster, err := newClusterPair(srcConfig, dstConfig)
 	if err != nil {
 		return err
 	}
-	logger.Debug("✅ kubernetes client created")
+	logger.Debug("✅ kubernetes clients created")
+
+	switch {
+	case c.Selector != "" && c.FromFile != "":
+		return errors.New(errMutuallyExclusive)
+
+	case c.FromFile != "":
+		items, err := itemsFromFile(c.FromFile)
+		if err != nil {
+			return err
+		}
+		return c.migrateBatch(ctx, logger, cluster, items)
+
+	case c.Selector != "":
+		mapping, err := resource.MappingFor(cluster.srcMapper, c.Claim)
+		if err != nil {
+			return errors.Wrap(err, errGetMapping)
+		}
+		items, err := itemsFromSelector(ctx, cluster.srcClient, mapping.Resource, c.Claim, c.Namespace, c.DestNamespace, c.Selector)
+		if err != nil {
+			return err
+		}
+		return c.migrateBatch(ctx, logger, cluster, items)
+
+	default:
+		return c.migrateItem(ctx, logger, cluster, item{
+			Claim:         c.Claim,
+			Namespace:     c.Namespace,
+			DestNamespace: c.DestNamespace,
+			Name:          c.Name,
+		})
+	}
+}
+
+// clusterPair bundles the dynamic client and REST mapper for the source and
+// destination clusters a Claim is migrated between. When no --dst-kubeconfig
+// or --dst-context is given, dst is the same cluster as src.
+type clusterPair struct {
+	srcClient dynamic.Interface
+	dstClient dynamic.Interface
+	srcMapper meta.RESTMapper
+	dstMapper meta.RESTMapper
+}
+
+func newClusterPair(srcConfig, dstConfig *rest.Config) (*clusterPair, error) {
+	srcClient, err := resource.NewDynamicClient(srcConfig)
+	if err != nil {
+		return nil, err
+	}
+	srcMapper, err := resource.NewRestMapper(srcConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, errRestMapper)
+	}
+
+	dstClient, dstMapper := srcClient, srcMapper
+	if dstConfig != srcConfig {
+		dstClient, err = resource.NewDynamicClient(dstConfig)
+		if err != nil {
+			return nil, err
+		}
+		dstMapper, err = resource.NewRestMapper(dstConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, errRestMapper)
+		}
+	}
+
+	return &clusterPair{srcClient: srcClient, dstClient: dstClient, srcMapper: srcMapper, dstMapper: dstMapper}, nil
+}
+
+func (c *Cmd) migrateItem(ctx context.Context, logger logging.Logger, cluster *clusterPair, it item) error {
+	logger = logger.WithValues("Resource", it.Claim, "Name", it.Name, "SrcNamespace", it.Namespace, "DestNamespace", it.DestNamespace)
 
 	// check if destination Namespace exists
 	ns := &v1.ObjectReference{
 		Kind:       "Namespace",
 		APIVersion: "v1",
-		Name:       c.DestNamespace,
+		Name:       it.DestNamespace,
 	}
 
-	_, re, err := resource.GetResource(ctx, dynamicClient, ns)
+	_, re, err := resource.GetResource(ctx, cluster.dstClient, cluster.dstMapper, ns)
 	if err != nil {
 		return errors.Wrap(err, errGetResource)
 	}
 	if !re {
-		return errors.Errorf("❌ cannot create new claim, namespace %s does not exist", c.DestNamespace)
+		return errors.Errorf("❌ cannot create new claim, namespace %s does not exist", it.DestNamespace)
 	}
 	logger.Info("✅ destination namespace exists")
 
-	rmapper, err := resource.NewRestMapper(kubeconfig)
-	if err != nil {
-		return errors.Wrap(err, errRestMapper)
-	}
-
-	res, name, err := c.getResourceAndName()
+	res, name, err := getResourceAndName(it.Claim, it.Name)
 	if err != nil {
 		return errors.Wrap(err, errInvalidResourceAndName)
 	}
 
-	mapping, err := resource.MappingFor(rmapper, res)
+	mapping, err := resource.MappingFor(cluster.srcMapper, res)
 	if err != nil {
 		return errors.Wrap(err, errGetMapping)
 	}
 	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
-		return errors.Wrap(err, errNotNamespaced)
+		return errors.New(errNotNamespaced)
+	}
+
+	// The destination cluster must serve the same Claim CRD, otherwise the
+	// create below would fail anyway; check it explicitly via discovery so
+	// cross-cluster migrations fail with a clear error up front.
+	if _, err := resource.MappingFor(cluster.dstMapper, res); err != nil {
+		return errors.Wrap(err, errDstCRDMissing)
 	}
 
 	srcClaimRef := &v1.ObjectReference{
 		Kind:       mapping.GroupVersionKind.Kind,
 		APIVersion: mapping.GroupVersionKind.GroupVersion().String(),
 		Name:       name,
-		Namespace:  c.Namespace,
+		Namespace:  it.Namespace,
 	}
 
-	srcClaim, re, err := resource.GetResource(ctx, dynamicClient, srcClaimRef)
+	srcClaim, re, err := resource.GetResource(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef)
 	if err != nil {
 		return errors.Wrap(err, errGetResource)
 	}
 	if !re {
 		logger.Info("❌ source Claim not found")
-		return errors.Wrap(err, "source Claim not found")
+		return errors.New("source Claim not found")
 	}
 	logger.Info("✅ source Claim exists")
 
+	// Capture the source Claim's original claim reference, finalizers and
+	// resourceRef up front, so they are available to roll back any mutating
+	// step even if a later Get sees an already-modified object. This is also
+	// where the XR reference comes from below: the XR always lives in the
+	// source cluster, regardless of which cluster dstClaim ends up carrying
+	// a resourceRef for.
+	srcXRC := claim.New(claim.WithGroupVersionKind(srcClaimRef.GroupVersionKind()))
+	srcXRC.Unstructured = *srcClaim.DeepCopy()
+	origClaimRef := srcXRC.GetReference()
+	origFinalizers := srcXRC.GetFinalizers()
+	origResourceRef := srcXRC.GetResourceReference()
+	xr := origResourceRef
+
+	isCrossCluster := cluster.dstClient != cluster.srcClient
+
 	dstClaimRef := &v1.ObjectReference{
 		Kind:       mapping.GroupVersionKind.Kind,
 		APIVersion: mapping.GroupVersionKind.GroupVersion().String(),
 		Name:       name,
-		Namespace:  c.DestNamespace,
+		Namespace:  it.DestNamespace,
 	}
 
 	// check if destination Claim exists
-	_, re, err = resource.GetResource(ctx, dynamicClient, dstClaimRef)
+	_, re, err = resource.GetResource(ctx, cluster.dstClient, cluster.dstMapper, dstClaimRef)
 	if err != nil {
 		return errors.Wrap(err, errGetResource)
 	}
 	if re {
-		return errors.Errorf("Cannot create new claim: claim %s in namespace %s already exists ", name, c.DestNamespace)
+		return errors.Errorf("Cannot create new claim: claim %s in namespace %s already exists ", name, it.DestNamespace)
 	}
 
-	// create the destination Claim
+	// build the destination Claim
 	dstClaim := srcClaim.DeepCopy()
-	dstClaim.SetNamespace(c.DestNamespace)
+	dstClaim.SetNamespace(it.DestNamespace)
 	_ = fieldpath.Pave(dstClaim.Object).SetValue("metadata.resourceVersion", "")
 
-	dstClaimUnstructured, err := resource.CreateResource(ctx, dynamicClient, dstClaimRef, dstClaim)
-	if err != nil {
-		return errors.Wrap(err, errCreateDestClaim)
+	if isCrossCluster && c.AdoptXR == adoptXRRecreate {
+		// The XR lives only in the source cluster and recreate mode leaves
+		// it there (see the switch below), so the destination Claim must
+		// not keep pointing at it: strip resourceRef so Crossplane
+		// provisions a brand new XR for it in the destination cluster.
+		dstXRC := claim.New(claim.WithGroupVersionKind(srcClaimRef.GroupVersionKind()))
+		dstXRC.Unstructured = *dstClaim
+		dstXRC.SetResourceReference(nil)
+		dstClaim = &dstXRC.Unstructured
 	}
 
-	// Get the XR associated with the claim
-	xrc := claim.New(
-		claim.WithGroupVersionKind(srcClaimRef.GroupVersionKind()),
-	)
-	xrc.Unstructured = *dstClaimUnstructured.DeepCopy()
-	xr := xrc.GetResourceReference()
+	var rollback rollbackStack
 
-	// Update the Composite
-	err = resource.UpdateCompositeWithNewClaim(ctx, dynamicClient, xr, xrc)
-	if err != nil {
-		return errors.Wrap(err, "unable to update composite")
+	if c.DryRun {
+		logger.Info("🔍 dry-run: would create destination Claim", "name", dstClaimRef.Name, "namespace", dstClaimRef.Namespace)
+		if c.Diff {
+			if err := c.printDiff("destination claim/"+dstClaimRef.Name, nil, dstClaim); err != nil {
+				return err
+			}
+		}
+	} else {
+		var err2 error
+		dstClaim, err2 = resource.CreateResource(ctx, cluster.dstClient, cluster.dstMapper, dstClaimRef, dstClaim)
+		if err2 != nil {
+			return errors.Wrap(err2, errCreateDestClaim)
+		}
+		if !c.NoRollback {
+			rollback.push(fmt.Sprintf("delete destination claim %s/%s", dstClaimRef.Namespace, dstClaimRef.Name), func(ctx context.Context) error {
+				return resource.DeleteResource(ctx, cluster.dstClient, cluster.dstMapper, dstClaimRef)
+			})
+		}
+	}
+
+	// Point the Composite at the new Claim. The XR always lives in the
+	// source cluster, but what "pointing at it" means depends on whether
+	// this is a single-cluster move or a cross-cluster one:
+	//
+	//   - single cluster: the XR is re-pointed in place at the relocated
+	//     Claim, exactly as for a same-cluster namespace move.
+	//   - cross-cluster, --adopt-xr=import: the XR is re-created in the
+	//     destination cluster with the same claimRef, so it (and its
+	//     composed resources) are adopted rather than re-provisioned. The
+	//     source XR is left untouched.
+	//   - cross-cluster, --adopt-xr=recreate: the source XR is left alone
+	//     entirely; the destination Claim built above has no resourceRef,
+	//     so Crossplane provisions a brand new XR for it.
+	switch {
+	case !isCrossCluster:
+		dstXRC := claim.New(claim.WithGroupVersionKind(srcClaimRef.GroupVersionKind()))
+		dstXRC.Unstructured = *dstClaim.DeepCopy()
+
+		if c.DryRun {
+			before, after, err := resource.ComputeCompositeUpdate(ctx, cluster.srcClient, cluster.srcMapper, xr, dstXRC)
+			if err != nil {
+				return errors.Wrap(err, "unable to plan composite update")
+			}
+			logger.Info("🔍 dry-run: would update composite claimRef and claim-namespace label", "name", xr.Name)
+			if c.Diff {
+				if err := c.printDiff("composite/"+xr.Name, &before.Unstructured, &after.Unstructured); err != nil {
+					return err
+				}
+			}
+			break
+		}
+
+		if err := resource.UpdateCompositeWithNewClaim(ctx, cluster.srcClient, cluster.srcMapper, xr, dstXRC); err != nil {
+			if !c.NoRollback {
+				rollback.run(ctx, logger)
+			}
+			return errors.Wrap(err, "unable to update composite")
+		}
+		logger.Info("✅ XR updated with new Claim", "name", xr.Name)
+		if !c.NoRollback {
+			rollback.push(fmt.Sprintf("restore composite %s claimRef", xr.Name), func(ctx context.Context) error {
+				return resource.RestoreComposite(ctx, cluster.srcClient, cluster.srcMapper, xr, origClaimRef, it.Namespace)
+			})
+		}
+
+	case c.AdoptXR == adoptXRImport:
+		if c.DryRun {
+			logger.Info("🔍 dry-run: would re-create XR in destination cluster", "name", xr.Name)
+			break
+		}
+
+		if err := importXR(ctx, cluster, xr); err != nil {
+			if !c.NoRollback {
+				rollback.run(ctx, logger)
+			}
+			return errors.Wrap(err, "unable to import XR into destination cluster")
+		}
+		logger.Info("✅ XR imported into destination cluster", "name", xr.Name)
+		if !c.NoRollback {
+			rollback.push(fmt.Sprintf("delete recreated XR %s from destination cluster", xr.Name), func(ctx context.Context) error {
+				return resource.DeleteResource(ctx, cluster.dstClient, cluster.dstMapper, xr)
+			})
+		}
+
+	case c.AdoptXR == adoptXRRecreate:
+		if c.DryRun {
+			logger.Info("🔍 dry-run: would leave source XR untouched, destination Claim will provision a new one", "name", xr.Name)
+			break
+		}
+		logger.Info("✅ source XR left untouched, destination Claim will provision a new one", "name", xr.Name)
+
+	default:
+		return errors.Errorf("unknown --adopt-xr mode %q", c.AdoptXR)
+	}
+
+	if c.DryRun {
+		before, after, err := resource.ComputeSourceClaimCleanup(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef)
+		if err != nil {
+			return errors.Wrap(err, "unable to plan source claim cleanup")
+		}
+		logger.Info("🔍 dry-run: would strip finalizers/resourceRef from source Claim and delete it", "name", srcClaimRef.Name)
+		if c.Diff {
+			if err := c.printDiff("source claim/"+srcClaimRef.Name, &before.Unstructured, &after.Unstructured); err != nil {
+				return err
+			}
+		}
+		logger.Info("✅ dry-run complete, no changes were made")
+		return nil
 	}
-	logger.Info("✅ XR updated with new Claim", "name", xr.Name)
+
 	logger.Info("✅ Migration complete")
 
+	if !c.NoRollback {
+		rollback.push(fmt.Sprintf("restore source claim %s/%s finalizers/resourceRef", srcClaimRef.Namespace, srcClaimRef.Name), func(ctx context.Context) error {
+			return resource.RestoreSourceClaim(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef, origFinalizers, origResourceRef)
+		})
+	}
+
 	// Delete the Source Claim
-	err = resource.DeleteSourceClaim(ctx, dynamicClient, srcClaimRef)
+	err = resource.DeleteSourceClaim(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef)
 	if err != nil {
+		if !c.NoRollback {
+			rollback.run(ctx, logger)
+		}
 		return errors.Wrap(err, "unable to delete source claim")
 	}
 	logger.Info("✅ source Claim deleted", "name", srcClaimRef.Name)
@@ -168,30 +411,66 @@ func (c *Cmd) Run(logger logging.Logger) error {
 	return nil
 }
 
-func (c *Cmd) getResourceAndName() (string, string, error) {
+const (
+	adoptXRRecreate = "recreate"
+	adoptXRImport   = "import"
+)
+
+// importXR re-creates the XR referenced by xr in the destination cluster, so
+// that a cross-cluster migration can adopt the existing composed resource
+// tree instead of provisioning a new one.
+func importXR(ctx context.Context, cluster *clusterPair, xr *v1.ObjectReference) error {
+	xru, re, err := resource.GetResource(ctx, cluster.srcClient, cluster.srcMapper, xr)
+	if err != nil {
+		return errors.Wrap(err, errGetResource)
+	}
+	if !re {
+		return errors.Errorf("composite %s not found in source cluster", xr.Name)
+	}
+
+	dstXR := xru.DeepCopy()
+	_ = fieldpath.Pave(dstXR.Object).SetValue("metadata.resourceVersion", "")
+	_ = fieldpath.Pave(dstXR.Object).SetValue("metadata.uid", "")
+
+	_, err = resource.CreateResource(ctx, cluster.dstClient, cluster.dstMapper, xr, dstXR)
+	return err
+}
+
+// printDiff renders and prints the unified diff between before and after,
+// both of which may be nil to represent a created or deleted object.
+func (c *Cmd) printDiff(name string, before, after *unstructured.Unstructured) error {
+	text, err := resource.Diff(name, before, after)
+	if err != nil {
+		return errors.Wrap(err, "unable to render diff")
+	}
+	fmt.Print(text)
+	return nil
+}
+
+func getResourceAndName(claimArg, nameArg string) (string, string, error) {
 	// If no resource was provided, error out (should never happen as it's
 	// required by Kong)
-	if c.Claim == "" {
+	if claimArg == "" {
 		return "", "", errors.New(errInvalidResource)
 	}
 
 	// Split the resource into its components
-	splittedResource := strings.Split(c.Claim, "/")
+	splittedResource := strings.Split(claimArg, "/")
 	length := len(splittedResource)
 
 	if length == 1 {
 		// If no name is provided, error out
-		if c.Name == "" {
+		if nameArg == "" {
 			return "", "", errors.New(errMissingName)
 		}
 
 		// Resource has only kind and the name is separately provided
-		return splittedResource[0], c.Name, nil
+		return splittedResource[0], nameArg, nil
 	}
 
 	if length == 2 {
 		// If a name is separately provided, error out
-		if c.Name != "" {
+		if nameArg != "" {
 			return "", "", errors.New(errNameDoubled)
 		}
 
@@ -0,0 +1,254 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	ktesting "k8s.io/client-go/testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+var (
+	testClaimGVK = schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "ExampleClaim"}
+	testXRGVK    = schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "XExample"}
+	testNSGVK    = schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+
+	testClaimArg = "exampleclaim.v1.example.org"
+)
+
+// newTestMapper builds a RESTMapper mapping the fixed test Claim, XR and
+// Namespace kinds above to their resources, so tests don't need a live
+// discovery client.
+func newTestMapper() meta.RESTMapper {
+	m := meta.NewDefaultRESTMapper([]schema.GroupVersion{testClaimGVK.GroupVersion(), testXRGVK.GroupVersion(), testNSGVK.GroupVersion()})
+	m.AddSpecific(testClaimGVK, testClaimGVK.GroupVersion().WithResource("exampleclaims"), testClaimGVK.GroupVersion().WithResource("exampleclaim"), meta.RESTScopeNamespace)
+	m.AddSpecific(testXRGVK, testXRGVK.GroupVersion().WithResource("xexamples"), testXRGVK.GroupVersion().WithResource("xexample"), meta.RESTScopeRoot)
+	m.AddSpecific(testNSGVK, testNSGVK.GroupVersion().WithResource("namespaces"), testNSGVK.GroupVersion().WithResource("namespace"), meta.RESTScopeRoot)
+	return m
+}
+
+func newTestClaim(namespace, name, xrName string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.org/v1",
+		"kind":       "ExampleClaim",
+		"metadata": map[string]interface{}{
+			"namespace":  namespace,
+			"name":       name,
+			"finalizers": []interface{}{"finalizer.apiextensions.crossplane.io"},
+		},
+		"spec": map[string]interface{}{
+			"resourceRef": map[string]interface{}{
+				"apiVersion": "example.org/v1",
+				"kind":       "XExample",
+				"name":       xrName,
+			},
+		},
+	}}
+	return u
+}
+
+func newTestXR(name, claimNamespace, claimName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.org/v1",
+		"kind":       "XExample",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"labels": map[string]interface{}{
+				"crossplane.io/claim-namespace": claimNamespace,
+			},
+		},
+		"spec": map[string]interface{}{
+			"claimRef": map[string]interface{}{
+				"apiVersion": "example.org/v1",
+				"kind":       "ExampleClaim",
+				"name":       claimName,
+				"namespace":  claimNamespace,
+			},
+		},
+	}}
+}
+
+func newTestNamespace(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+	}}
+}
+
+func TestMigrateItemCrossClusterRecreateLeavesSourceXRAlone(t *testing.T) {
+	mapper := newTestMapper()
+	src := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestClaim("src-ns", "my-claim", "xr-1"), newTestXR("xr-1", "src-ns", "my-claim"))
+	dst := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestNamespace("dst-ns"))
+
+	cluster := &clusterPair{srcClient: src, dstClient: dst, srcMapper: mapper, dstMapper: mapper}
+
+	c := &Cmd{AdoptXR: adoptXRRecreate, NoRollback: true}
+	err := c.migrateItem(context.Background(), logging.NewNopLogger(), cluster, item{
+		Claim:         testClaimArg,
+		Namespace:     "src-ns",
+		DestNamespace: "dst-ns",
+		Name:          "my-claim",
+	})
+	if err != nil {
+		t.Fatalf("migrateItem(...): unexpected error: %v", err)
+	}
+
+	dstClaim, err := dst.Resource(testClaimGVK.GroupVersion().WithResource("exampleclaims")).Namespace("dst-ns").Get(context.Background(), "my-claim", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination claim was not created: %v", err)
+	}
+	if ref, found, _ := unstructured.NestedMap(dstClaim.Object, "spec", "resourceRef"); found {
+		t.Errorf("destination claim still has spec.resourceRef %v, want it stripped so Crossplane provisions a new XR", ref)
+	}
+
+	srcXR, err := src.Resource(testXRGVK.GroupVersion().WithResource("xexamples")).Get(context.Background(), "xr-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("source XR should still exist, got error: %v", err)
+	}
+	claimRef, _, _ := unstructured.NestedString(srcXR.Object, "spec", "claimRef", "namespace")
+	if claimRef != "src-ns" {
+		t.Errorf("source XR claimRef.namespace = %q, want it untouched at %q (recreate mode must not mutate the source XR)", claimRef, "src-ns")
+	}
+}
+
+func TestMigrateItemCrossClusterImportRecreatesXRInDestination(t *testing.T) {
+	mapper := newTestMapper()
+	src := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestClaim("src-ns", "my-claim", "xr-1"), newTestXR("xr-1", "src-ns", "my-claim"))
+	dst := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newTestNamespace("dst-ns"))
+
+	cluster := &clusterPair{srcClient: src, dstClient: dst, srcMapper: mapper, dstMapper: mapper}
+
+	c := &Cmd{AdoptXR: adoptXRImport, NoRollback: true}
+	err := c.migrateItem(context.Background(), logging.NewNopLogger(), cluster, item{
+		Claim:         testClaimArg,
+		Namespace:     "src-ns",
+		DestNamespace: "dst-ns",
+		Name:          "my-claim",
+	})
+	if err != nil {
+		t.Fatalf("migrateItem(...): unexpected error: %v", err)
+	}
+
+	dstClaim, err := dst.Resource(testClaimGVK.GroupVersion().WithResource("exampleclaims")).Namespace("dst-ns").Get(context.Background(), "my-claim", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("destination claim was not created: %v", err)
+	}
+	if name, found, _ := unstructured.NestedString(dstClaim.Object, "spec", "resourceRef", "name"); !found || name != "xr-1" {
+		t.Errorf("destination claim spec.resourceRef.name = %q (found=%v), want it to keep pointing at the imported XR %q", name, found, "xr-1")
+	}
+
+	if _, err := dst.Resource(testXRGVK.GroupVersion().WithResource("xexamples")).Get(context.Background(), "xr-1", metav1.GetOptions{}); err != nil {
+		t.Fatalf("XR was not re-created in the destination cluster: %v", err)
+	}
+
+	srcXR, err := src.Resource(testXRGVK.GroupVersion().WithResource("xexamples")).Get(context.Background(), "xr-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("source XR should still exist, got error: %v", err)
+	}
+	claimNS, _, _ := unstructured.NestedString(srcXR.Object, "spec", "claimRef", "namespace")
+	if claimNS != "src-ns" {
+		t.Errorf("source XR claimRef.namespace = %q, want it untouched at %q (import mode must not mutate the source XR)", claimNS, "src-ns")
+	}
+}
+
+func TestMigrateItemSingleClusterUpdatesComposite(t *testing.T) {
+	mapper := newTestMapper()
+	clusterClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newTestClaim("src-ns", "my-claim", "xr-1"),
+		newTestXR("xr-1", "src-ns", "my-claim"),
+		newTestNamespace("dst-ns"),
+	)
+
+	cluster := &clusterPair{srcClient: clusterClient, dstClient: clusterClient, srcMapper: mapper, dstMapper: mapper}
+
+	c := &Cmd{AdoptXR: adoptXRRecreate, NoRollback: true}
+	err := c.migrateItem(context.Background(), logging.NewNopLogger(), cluster, item{
+		Claim:         testClaimArg,
+		Namespace:     "src-ns",
+		DestNamespace: "dst-ns",
+		Name:          "my-claim",
+	})
+	if err != nil {
+		t.Fatalf("migrateItem(...): unexpected error: %v", err)
+	}
+
+	xr, err := clusterClient.Resource(testXRGVK.GroupVersion().WithResource("xexamples")).Get(context.Background(), "xr-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("XR should still exist, got error: %v", err)
+	}
+	claimNS, _, _ := unstructured.NestedString(xr.Object, "spec", "claimRef", "namespace")
+	if claimNS != "dst-ns" {
+		t.Errorf("XR claimRef.namespace = %q, want %q (single-cluster moves re-point the existing XR)", claimNS, "dst-ns")
+	}
+}
+
+// TestMigrateItemRollsBackOnDeleteSourceClaimFailure locks in the
+// compensating-action contract from rollback.go: when the last mutating
+// step (deleting the source Claim) fails, every earlier step must be undone,
+// leaving the cluster as close as possible to its pre-migration state. It
+// checks the resulting state, not the order the actions ran in.
+func TestMigrateItemRollsBackOnDeleteSourceClaimFailure(t *testing.T) {
+	mapper := newTestMapper()
+	clusterClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		newTestClaim("src-ns", "my-claim", "xr-1"),
+		newTestXR("xr-1", "src-ns", "my-claim"),
+		newTestNamespace("dst-ns"),
+	)
+	clusterClient.PrependReactor("delete", "exampleclaims", func(action ktesting.Action) (bool, runtime.Object, error) {
+		// Only the source claim's delete fails; rollback's delete of the
+		// destination claim must still go through.
+		if action.GetNamespace() != "src-ns" {
+			return false, nil, nil
+		}
+		return true, nil, fmt.Errorf("simulated apiserver failure deleting source claim")
+	})
+
+	cluster := &clusterPair{srcClient: clusterClient, dstClient: clusterClient, srcMapper: mapper, dstMapper: mapper}
+
+	c := &Cmd{AdoptXR: adoptXRRecreate}
+	err := c.migrateItem(context.Background(), logging.NewNopLogger(), cluster, item{
+		Claim:         testClaimArg,
+		Namespace:     "src-ns",
+		DestNamespace: "dst-ns",
+		Name:          "my-claim",
+	})
+	if err == nil {
+		t.Fatal("migrateItem(...): expected error from failed source claim deletion, got nil")
+	}
+
+	if _, err := clusterClient.Resource(testClaimGVK.GroupVersion().WithResource("exampleclaims")).Namespace("dst-ns").Get(context.Background(), "my-claim", metav1.GetOptions{}); err == nil {
+		t.Error("destination claim still exists, want it removed by rollback")
+	}
+
+	xr, err := clusterClient.Resource(testXRGVK.GroupVersion().WithResource("xexamples")).Get(context.Background(), "xr-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("source XR should still exist, got error: %v", err)
+	}
+	claimNS, _, _ := unstructured.NestedString(xr.Object, "spec", "claimRef", "namespace")
+	if claimNS != "src-ns" {
+		t.Errorf("XR claimRef.namespace = %q, want rollback to restore it to %q", claimNS, "src-ns")
+	}
+	labelNS, _, _ := unstructured.NestedString(xr.Object, "metadata", "labels", "crossplane.io/claim-namespace")
+	if labelNS != "src-ns" {
+		t.Errorf("XR claim-namespace label = %q, want rollback to restore it to %q", labelNS, "src-ns")
+	}
+
+	srcClaim, err := clusterClient.Resource(testClaimGVK.GroupVersion().WithResource("exampleclaims")).Namespace("src-ns").Get(context.Background(), "my-claim", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("source claim should still exist after a failed delete, got error: %v", err)
+	}
+	if finalizers := srcClaim.GetFinalizers(); len(finalizers) != 1 || finalizers[0] != "finalizer.apiextensions.crossplane.io" {
+		t.Errorf("source claim finalizers = %v, want rollback to restore the original finalizer", finalizers)
+	}
+}
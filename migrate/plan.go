@@ -0,0 +1,384 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crossplane/crossplane-runtime/pkg/errors"
+	"github.com/crossplane/crossplane-runtime/pkg/fieldpath"
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
+
+	"github.com/crossplane-contrib/claim-migrator/resource"
+)
+
+const (
+	planAPIVersion = "claim-migrator.crossplane.io/v1alpha1"
+	planKind       = "MigrationPlan"
+
+	errWritePlan = "cannot write plan"
+	errReadPlan  = "cannot read plan file"
+	errParsePlan = "cannot parse plan file"
+)
+
+// planRef identifies the object a plan step acts on.
+type planRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// jsonPatchOp is a single RFC 6902-style JSON Patch operation. Path is given
+// as pre-split segments rather than a "/"-joined pointer, so map keys that
+// themselves contain "/" or "." (e.g. the crossplane.io/claim-namespace
+// label) don't need escaping.
+type jsonPatchOp struct {
+	Op    string   `json:"op"`
+	Path  []string `json:"path"`
+	Value string   `json:"value"`
+}
+
+// Plan is a self-contained, portable description of a single Claim
+// migration, produced by 'migrate plan' and consumed by
+// 'migrate apply-plan'. It can be checked into Git and rolled out by a
+// GitOps controller instead of running the CLI directly against a cluster.
+type Plan struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// DestClaim is the Claim to create in the destination namespace.
+	DestClaim *unstructured.Unstructured `json:"destClaim"`
+
+	// Composite and CompositePatch together describe the update to make to
+	// the XR so it points at DestClaim instead of SourceClaim.
+	Composite      planRef       `json:"composite"`
+	CompositePatch []jsonPatchOp `json:"compositePatch"`
+
+	// SourceClaim and SourceCleanup describe the source Claim with its
+	// finalizers and resourceRef cleared, ready for deletion.
+	SourceClaim   planRef                    `json:"sourceClaim"`
+	SourceCleanup *unstructured.Unstructured `json:"sourceCleanup"`
+	DeleteSource  bool                       `json:"deleteSource"`
+}
+
+// PlanCmd renders a migration as a portable Plan document instead of
+// executing it, so it can be reviewed and applied via 'migrate apply-plan'
+// (or a GitOps controller that understands the format).
+type PlanCmd struct {
+	Claim         string `arg:"" optional:"" help:"Kind of the Crossplane Claim, accepts the 'TYPE[.VERSION][.GROUP][/NAME]' format."`
+	Namespace     string `short:"n" name:"namespace" help:"Namespace of the existing Claim." default:"default"`
+	DestNamespace string `help:"Destination namespace for the Claim."`
+	Name          string `arg:"" optional:"" help:"(Optional) Name of the Crossplane Claim, can be passed as part of the <claim> claim.example.com/name."`
+
+	Out string `help:"Path to write the plan YAML to. Use '-' for stdout." default:"-"`
+
+	SrcKubeconfig string `help:"Path to the kubeconfig for the source cluster. Defaults to the ambient kubeconfig."`
+	DstKubeconfig string `help:"Path to the kubeconfig for the destination cluster. Defaults to --src-kubeconfig."`
+	SrcContext    string `help:"kubeconfig context to use for the source cluster."`
+	DstContext    string `help:"kubeconfig context to use for the destination cluster. Defaults to --src-context."`
+}
+
+func (c *PlanCmd) Run(logger logging.Logger) error {
+	ctx := context.Background()
+
+	srcConfig, err := resource.LoadConfig(c.SrcKubeconfig, c.SrcContext)
+	if err != nil {
+		return errors.Wrap(err, errSrcKubeConfig)
+	}
+
+	dstConfig := srcConfig
+	if c.DstKubeconfig != "" || c.DstContext != "" {
+		dstKubeconfig, dstContext := c.DstKubeconfig, c.DstContext
+		if dstKubeconfig == "" {
+			dstKubeconfig = c.SrcKubeconfig
+		}
+		if dstContext == "" {
+			dstContext = c.SrcContext
+		}
+		dstConfig, err = resource.LoadConfig(dstKubeconfig, dstContext)
+		if err != nil {
+			return errors.Wrap(err, errDstKubeConfig)
+		}
+	}
+
+	cluster, err := newClusterPair(srcConfig, dstConfig)
+	if err != nil {
+		return err
+	}
+
+	res, name, err := getResourceAndName(c.Claim, c.Name)
+	if err != nil {
+		return errors.Wrap(err, errInvalidResourceAndName)
+	}
+
+	mapping, err := resource.MappingFor(cluster.srcMapper, res)
+	if err != nil {
+		return errors.Wrap(err, errGetMapping)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return errors.New(errNotNamespaced)
+	}
+	if _, err := resource.MappingFor(cluster.dstMapper, res); err != nil {
+		return errors.Wrap(err, errDstCRDMissing)
+	}
+
+	srcClaimRef := &v1.ObjectReference{
+		Kind:       mapping.GroupVersionKind.Kind,
+		APIVersion: mapping.GroupVersionKind.GroupVersion().String(),
+		Name:       name,
+		Namespace:  c.Namespace,
+	}
+
+	srcClaim, re, err := resource.GetResource(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef)
+	if err != nil {
+		return errors.Wrap(err, errGetResource)
+	}
+	if !re {
+		return errors.New("source Claim not found")
+	}
+
+	xrc := claim.New(claim.WithGroupVersionKind(srcClaimRef.GroupVersionKind()))
+	xrc.Unstructured = *srcClaim.DeepCopy()
+	xr := xrc.GetResourceReference()
+	if xr == nil {
+		return errors.New("source Claim has no composite resource reference")
+	}
+
+	dstClaim := srcClaim.DeepCopy()
+	dstClaim.SetNamespace(c.DestNamespace)
+	_ = fieldpath.Pave(dstClaim.Object).SetValue("metadata.resourceVersion", "")
+	_ = fieldpath.Pave(dstClaim.Object).SetValue("metadata.uid", "")
+
+	_, sourceCleanup, err := resource.ComputeSourceClaimCleanup(ctx, cluster.srcClient, cluster.srcMapper, srcClaimRef)
+	if err != nil {
+		return errors.Wrap(err, "unable to plan source claim cleanup")
+	}
+
+	plan := &Plan{
+		APIVersion: planAPIVersion,
+		Kind:       planKind,
+		DestClaim:  dstClaim,
+		Composite: planRef{
+			APIVersion: xr.APIVersion,
+			Kind:       xr.Kind,
+			Name:       xr.Name,
+		},
+		CompositePatch: []jsonPatchOp{
+			{Op: "replace", Path: []string{"spec", "claimRef", "namespace"}, Value: c.DestNamespace},
+			{Op: "replace", Path: []string{"metadata", "labels", "crossplane.io/claim-namespace"}, Value: c.DestNamespace},
+		},
+		SourceClaim: planRef{
+			APIVersion: srcClaimRef.APIVersion,
+			Kind:       srcClaimRef.Kind,
+			Namespace:  srcClaimRef.Namespace,
+			Name:       srcClaimRef.Name,
+		},
+		SourceCleanup: &sourceCleanup.Unstructured,
+		DeleteSource:  true,
+	}
+
+	b, err := yaml.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(err, "cannot marshal plan")
+	}
+
+	if c.Out == "-" {
+		fmt.Print(string(b))
+		return nil
+	}
+	if err := os.WriteFile(c.Out, b, 0o600); err != nil {
+		return errors.Wrap(err, errWritePlan)
+	}
+	logger.Info("✅ plan written", "path", c.Out)
+	return nil
+}
+
+// ApplyPlanCmd executes a Plan document produced by 'migrate plan'. Every
+// step checks the current state of its target before mutating it, so
+// running the same plan more than once is safe.
+type ApplyPlanCmd struct {
+	File string `arg:"" help:"Path to a plan YAML file produced by 'migrate plan'."`
+
+	SrcKubeconfig string `help:"Path to the kubeconfig for the source cluster. Defaults to the ambient kubeconfig."`
+	DstKubeconfig string `help:"Path to the kubeconfig for the destination cluster. Defaults to --src-kubeconfig."`
+	SrcContext    string `help:"kubeconfig context to use for the source cluster."`
+	DstContext    string `help:"kubeconfig context to use for the destination cluster. Defaults to --src-context."`
+}
+
+func (c *ApplyPlanCmd) Run(logger logging.Logger) error {
+	ctx := context.Background()
+
+	b, err := os.ReadFile(c.File) //nolint:gosec // path is an operator-provided CLI flag.
+	if err != nil {
+		return errors.Wrap(err, errReadPlan)
+	}
+	plan := &Plan{}
+	if err := yaml.Unmarshal(b, plan); err != nil {
+		return errors.Wrap(err, errParsePlan)
+	}
+
+	srcConfig, err := resource.LoadConfig(c.SrcKubeconfig, c.SrcContext)
+	if err != nil {
+		return errors.Wrap(err, errSrcKubeConfig)
+	}
+
+	dstConfig := srcConfig
+	if c.DstKubeconfig != "" || c.DstContext != "" {
+		dstKubeconfig, dstContext := c.DstKubeconfig, c.DstContext
+		if dstKubeconfig == "" {
+			dstKubeconfig = c.SrcKubeconfig
+		}
+		if dstContext == "" {
+			dstContext = c.SrcContext
+		}
+		dstConfig, err = resource.LoadConfig(dstKubeconfig, dstContext)
+		if err != nil {
+			return errors.Wrap(err, errDstKubeConfig)
+		}
+	}
+
+	cluster, err := newClusterPair(srcConfig, dstConfig)
+	if err != nil {
+		return err
+	}
+
+	dstClaimRef := &v1.ObjectReference{
+		APIVersion: plan.DestClaim.GetAPIVersion(),
+		Kind:       plan.DestClaim.GetKind(),
+		Namespace:  plan.DestClaim.GetNamespace(),
+		Name:       plan.DestClaim.GetName(),
+	}
+	_, re, err := resource.GetResource(ctx, cluster.dstClient, cluster.dstMapper, dstClaimRef)
+	if err != nil {
+		return errors.Wrap(err, errGetResource)
+	}
+	if re {
+		logger.Info("destination claim already exists, skipping create", "name", dstClaimRef.Name, "namespace", dstClaimRef.Namespace)
+	} else {
+		if _, err := resource.CreateResource(ctx, cluster.dstClient, cluster.dstMapper, dstClaimRef, plan.DestClaim); err != nil {
+			return errors.Wrap(err, errCreateDestClaim)
+		}
+		logger.Info("✅ destination claim created", "name", dstClaimRef.Name, "namespace", dstClaimRef.Namespace)
+	}
+
+	compositeRef := &v1.ObjectReference{
+		APIVersion: plan.Composite.APIVersion,
+		Kind:       plan.Composite.Kind,
+		Name:       plan.Composite.Name,
+	}
+	if err := applyCompositePatch(ctx, cluster, compositeRef, plan.CompositePatch); err != nil {
+		return errors.Wrap(err, "unable to patch composite")
+	}
+	logger.Info("✅ composite patched", "name", compositeRef.Name)
+
+	sourceClaimRef := &v1.ObjectReference{
+		APIVersion: plan.SourceClaim.APIVersion,
+		Kind:       plan.SourceClaim.Kind,
+		Namespace:  plan.SourceClaim.Namespace,
+		Name:       plan.SourceClaim.Name,
+	}
+	_, re, err = resource.GetResource(ctx, cluster.srcClient, cluster.srcMapper, sourceClaimRef)
+	if err != nil {
+		return errors.Wrap(err, errGetResource)
+	}
+	if !re {
+		logger.Info("source claim already gone, nothing to clean up", "name", sourceClaimRef.Name, "namespace", sourceClaimRef.Namespace)
+		return nil
+	}
+
+	if plan.DeleteSource {
+		if err := applyPlannedSourceCleanup(ctx, cluster, sourceClaimRef, plan.SourceCleanup); err != nil {
+			return errors.Wrap(err, "unable to delete source claim")
+		}
+		logger.Info("✅ source claim deleted", "name", sourceClaimRef.Name)
+		return nil
+	}
+
+	logger.Info("✅ apply-plan complete")
+	return nil
+}
+
+// applyPlannedSourceCleanup updates the source Claim to exactly the
+// finalizers and resourceRef reviewed in cleanup (plan.SourceCleanup),
+// rather than recomputing the strip from whatever state the source Claim is
+// in at apply time, so the applied change matches what was checked into
+// Git. The update carries the resourceVersion read at apply time, so any
+// change made to the source Claim between 'plan' and 'apply-plan' is
+// overwritten; only a write landing between that read and the update is
+// caught as a conflict, and the update is then retried.
+func applyPlannedSourceCleanup(ctx context.Context, cluster *clusterPair, ref *v1.ObjectReference, cleanup *unstructured.Unstructured) error {
+	gvr, err := resource.GVRFor(cluster.srcMapper, ref.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	res := cluster.srcClient.Resource(gvr).Namespace(ref.Namespace)
+
+	updateErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u, getErr := res.Get(ctx, ref.Name, metav1.GetOptions{})
+		if getErr != nil {
+			return getErr
+		}
+
+		desired := cleanup.DeepCopy()
+		desired.SetResourceVersion(u.GetResourceVersion())
+
+		_, updateErr := res.Update(ctx, desired, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deleteErr := res.Delete(ctx, ref.Name, metav1.DeleteOptions{})
+		if k8serrors.IsNotFound(deleteErr) {
+			return nil
+		}
+		return deleteErr
+	})
+}
+
+// applyCompositePatch applies patch to the composite referred to by ref,
+// retrying on update conflicts. It is a no-op if the composite already
+// matches every patch op.
+func applyCompositePatch(ctx context.Context, cluster *clusterPair, ref *v1.ObjectReference, patch []jsonPatchOp) error {
+	gvr, err := resource.GVRFor(cluster.srcMapper, ref.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		u, err := cluster.srcClient.Resource(gvr).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for _, op := range patch {
+			cur, _, _ := unstructured.NestedString(u.Object, op.Path...)
+			if cur == op.Value {
+				continue
+			}
+			if err := unstructured.SetNestedField(u.Object, op.Value, op.Path...); err != nil {
+				return errors.Wrapf(err, "cannot apply patch op to %v", op.Path)
+			}
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+
+		_, err = cluster.srcClient.Resource(gvr).Update(ctx, u, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,38 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/crossplane/crossplane-runtime/pkg/logging"
+)
+
+// rollbackAction is a single compensating action that undoes one mutating
+// migration step.
+type rollbackAction struct {
+	description string
+	undo        func(ctx context.Context) error
+}
+
+// rollbackStack records the compensating action for each mutating step taken
+// during a migration, so they can be undone in LIFO order if a later step
+// fails.
+type rollbackStack struct {
+	actions []rollbackAction
+}
+
+func (r *rollbackStack) push(description string, undo func(ctx context.Context) error) {
+	r.actions = append(r.actions, rollbackAction{description: description, undo: undo})
+}
+
+// run executes every registered action in LIFO order, logging each one. It
+// does not stop on the first failed action, since leaving later actions
+// un-attempted would leave the cluster further from its pre-migration state.
+func (r *rollbackStack) run(ctx context.Context, logger logging.Logger) {
+	for i := len(r.actions) - 1; i >= 0; i-- {
+		a := r.actions[i]
+		logger.Info("↩️ rolling back", "action", a.description)
+		if err := a.undo(ctx); err != nil {
+			logger.Info("❌ rollback action failed", "action", a.description, "error", err.Error())
+		}
+	}
+}
@@ -27,11 +27,15 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+
+	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 const (
 	errFmtResourceTypeNotFound = "the server doesn't have a resource type %q"
 	errGetDiscoveryClient      = "cannot get discovery client"
+	errLoadKubeconfig          = "cannot load kubeconfig"
 )
 
 func NewDynamicClient(config *rest.Config) (dynamic.Interface, error) {
@@ -43,6 +47,33 @@ func NewDynamicClient(config *rest.Config) (dynamic.Interface, error) {
 	return dynClient, nil
 }
 
+// LoadConfig resolves a *rest.Config for a cluster endpoint. When both
+// kubeconfigPath and contextName are empty it falls back to the default
+// single-cluster resolution ($KUBECONFIG if set, otherwise in-cluster config,
+// then ~/.kube/config), preserving prior behavior. This allows the migrate
+// command to target independent source and destination clusters.
+func LoadConfig(kubeconfigPath, contextName string) (*rest.Config, error) {
+	if kubeconfigPath == "" && contextName == "" {
+		return ctrl.GetConfig()
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfigPath != "" {
+		loadingRules.ExplicitPath = kubeconfigPath
+	}
+
+	overrides := &clientcmd.ConfigOverrides{}
+	if contextName != "" {
+		overrides.CurrentContext = contextName
+	}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, errLoadKubeconfig)
+	}
+	return config, nil
+}
+
 func NewRestMapper(kubeconfig *rest.Config) (meta.RESTMapper, error) {
 	discoveryClient, err := discovery.NewDiscoveryClientForConfig(kubeconfig)
 	if err != nil {
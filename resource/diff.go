@@ -0,0 +1,49 @@
+package resource
+
+import (
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const errMarshalYAML = "cannot marshal object to YAML"
+
+// Diff renders a unified diff between the YAML representations of before and
+// after. Either may be nil, in which case it is treated as an empty object
+// (e.g. to render a diff for an object that is being created or deleted).
+func Diff(name string, before, after *unstructured.Unstructured) (string, error) {
+	fromYAML, err := toYAML(before)
+	if err != nil {
+		return "", err
+	}
+	toYAML, err := toYAML(after)
+	if err != nil {
+		return "", err
+	}
+
+	ud := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYAML),
+		B:        difflib.SplitLines(toYAML),
+		FromFile: name,
+		ToFile:   name,
+		Context:  3,
+	}
+
+	text, err := difflib.GetUnifiedDiffString(ud)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot render diff")
+	}
+	return text, nil
+}
+
+func toYAML(u *unstructured.Unstructured) (string, error) {
+	if u == nil {
+		return "", nil
+	}
+	b, err := yaml.Marshal(u.Object)
+	if err != nil {
+		return "", errors.Wrap(err, errMarshalYAML)
+	}
+	return string(b), nil
+}
@@ -2,9 +2,9 @@ package resource
 
 import (
 	"context"
-	"strings"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/crossplane/crossplane-runtime/pkg/resource/unstructured/claim"
@@ -17,15 +17,26 @@ import (
 	"k8s.io/client-go/util/retry"
 )
 
+// GVRFor resolves the GroupVersionResource that serves gvk, using rmapper's
+// discovery-backed RESTMapping. This replaces naive `kind.lower() + "s"`
+// pluralization, which breaks on irregular plurals (e.g. Policy -> policies,
+// Ingress -> ingresses).
+func GVRFor(rmapper meta.RESTMapper, gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	mapping, err := rmapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, errors.Wrapf(err, "cannot get REST mapping for %s", gvk.String())
+	}
+	return mapping.Resource, nil
+}
+
 // Get Resource gets a resource. Returns false
-func GetResource(ctx context.Context, client dynamic.Interface, ref *v1.ObjectReference) (*unstructured.Unstructured, bool, error) {
-	res := client.Resource(schema.GroupVersionResource{
-		Group:    ref.GroupVersionKind().Group,
-		Version:  ref.GroupVersionKind().Version,
-		Resource: strings.ToLower(ref.Kind) + "s",
-	}).Namespace(ref.Namespace)
-
-	u, err := res.Get(ctx, ref.Name, metav1.GetOptions{})
+func GetResource(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference) (*unstructured.Unstructured, bool, error) {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return nil, false, err
+	}
+
+	u, err := client.Resource(gvr).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			return nil, false, nil
@@ -37,20 +48,19 @@ func GetResource(ctx context.Context, client dynamic.Interface, ref *v1.ObjectRe
 }
 
 // Check if Resource Exists
-func ResourceExists(ctx context.Context, client dynamic.Interface, ref *v1.ObjectReference) (bool, error) {
-	_, re, err := GetResource(ctx, client, ref)
+func ResourceExists(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference) (bool, error) {
+	_, re, err := GetResource(ctx, client, rmapper, ref)
 	return re, err
 }
 
 // CreateResource creates a K8s resource using a dynamic Client, allowing us to create CRD types
-func CreateResource(ctx context.Context, client dynamic.Interface, ref *v1.ObjectReference, u *unstructured.Unstructured) (*unstructured.Unstructured, error) {
-	res := client.Resource(schema.GroupVersionResource{
-		Group:    ref.GroupVersionKind().Group,
-		Version:  ref.GroupVersionKind().Version,
-		Resource: strings.ToLower(ref.Kind) + "s",
-	}).Namespace(ref.Namespace)
-
-	u, err := res.Create(ctx, u, metav1.CreateOptions{})
+func CreateResource(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference, u *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+
+	u, err = client.Resource(gvr).Namespace(ref.Namespace).Create(ctx, u, metav1.CreateOptions{})
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create new claim")
 	}
@@ -58,63 +68,179 @@ func CreateResource(ctx context.Context, client dynamic.Interface, ref *v1.Objec
 	return u, nil
 }
 
+// ComputeCompositeUpdate fetches the Composite referred to by xrRef and
+// returns it alongside a copy with the claimRef/claim-namespace label updated
+// to point at xrcu. It performs no mutating calls, so it is safe to use to
+// preview a migration.
+func ComputeCompositeUpdate(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, xrRef *v1.ObjectReference, xrcu *claim.Unstructured) (before, after *composite.Unstructured, err error) {
+	gvr, err := GVRFor(rmapper, xrRef.GroupVersionKind())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	xru, getErr := client.Resource(gvr).Get(ctx, xrRef.Name, metav1.GetOptions{})
+	if getErr != nil {
+		return nil, nil, getErr
+	}
+
+	before = composite.New()
+	before.SetGroupVersionKind(xrRef.GroupVersionKind())
+	before.Unstructured = *xru.DeepCopy()
+
+	after = composite.New()
+	after.SetGroupVersionKind(xrRef.GroupVersionKind())
+	after.Unstructured = *xru.DeepCopy()
+	after.SetClaimReference(xrcu.GetReference())
+
+	labels := after.GetLabels()
+	labels["crossplane.io/claim-namespace"] = xrcu.GetNamespace()
+	after.SetLabels(labels)
+
+	return before, after, nil
+}
+
 // UpdateCompositeWithNewClaim updates the Composite to refer to the new Claim
-func UpdateCompositeWithNewClaim(ctx context.Context, client dynamic.Interface, xrRef *v1.ObjectReference, xrcu *claim.Unstructured) error {
-	res := schema.GroupVersionResource{
-		Group:    xrRef.GroupVersionKind().Group,
-		Version:  xrRef.GroupVersionKind().Version,
-		Resource: strings.ToLower(xrRef.Kind) + "s",
+func UpdateCompositeWithNewClaim(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, xrRef *v1.ObjectReference, xrcu *claim.Unstructured) error {
+	gvr, err := GVRFor(rmapper, xrRef.GroupVersionKind())
+	if err != nil {
+		return err
 	}
 
 	// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		xru, getErr := client.Resource(res).Get(ctx, xrRef.Name, metav1.GetOptions{})
+		_, after, computeErr := ComputeCompositeUpdate(ctx, client, rmapper, xrRef, xrcu)
+		if computeErr != nil {
+			return computeErr
+		}
+
+		_, updateErr := client.Resource(gvr).Update(ctx, &after.Unstructured, metav1.UpdateOptions{})
+		return updateErr
+	})
+	if retryErr != nil {
+		return retryErr
+	}
+	return nil
+}
+
+// DeleteResource deletes a K8s resource using a dynamic Client. It is a
+// no-op if the resource is already gone.
+func DeleteResource(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference) error {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	if err := client.Resource(gvr).Namespace(ref.Namespace).Delete(ctx, ref.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// RestoreComposite reverts the Composite referred to by xrRef's claimRef and
+// crossplane.io/claim-namespace label back to oldRef/oldNamespace. It is used
+// to roll back UpdateCompositeWithNewClaim if a later migration step fails.
+func RestoreComposite(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, xrRef *v1.ObjectReference, oldRef *v1.ObjectReference, oldNamespace string) error {
+	gvr, err := GVRFor(rmapper, xrRef.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		xru, getErr := client.Resource(gvr).Get(ctx, xrRef.Name, metav1.GetOptions{})
 		if getErr != nil {
 			return getErr
 		}
-		// Update values
+
 		xr := composite.New()
 		xr.SetGroupVersionKind(xrRef.GroupVersionKind())
 		xr.Unstructured = *xru.DeepCopy()
-		xr.SetClaimReference(xrcu.GetReference())
+		xr.SetClaimReference(oldRef)
 
 		labels := xr.GetLabels()
-		labels["crossplane.io/claim-namespace"] = xrcu.GetNamespace()
+		labels["crossplane.io/claim-namespace"] = oldNamespace
 		xr.SetLabels(labels)
 
-		_, updateErr := client.Resource(res).Update(context.TODO(), &xr.Unstructured, metav1.UpdateOptions{})
+		_, updateErr := client.Resource(gvr).Update(ctx, &xr.Unstructured, metav1.UpdateOptions{})
 		return updateErr
 	})
-	if retryErr != nil {
-		return retryErr
-	}
-	return nil
 }
 
-// DeleteSourceClaim removes references to the Composite before deleting
-func DeleteSourceClaim(ctx context.Context, client dynamic.Interface, ref *v1.ObjectReference) error {
-	res := client.Resource(schema.GroupVersionResource{
-		Group:    ref.GroupVersionKind().Group,
-		Version:  ref.GroupVersionKind().Version,
-		Resource: strings.ToLower(ref.Kind) + "s",
-	}).Namespace(ref.Namespace)
+// RestoreSourceClaim resets the source Claim's finalizers and resourceRef to
+// the values captured before migration. It is used to roll back
+// DeleteSourceClaim's finalizer/resourceRef strip if deleting the source
+// Claim then fails.
+func RestoreSourceClaim(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference, finalizers []string, resourceRef *v1.ObjectReference) error {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	res := client.Resource(gvr).Namespace(ref.Namespace)
 
-	// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
-	retryUpdateErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		u, getErr := res.Get(ctx, ref.Name, metav1.GetOptions{})
 		if getErr != nil {
+			if k8serrors.IsNotFound(getErr) {
+				// The source Claim is already gone, there is nothing left to restore.
+				return nil
+			}
 			return getErr
 		}
-		// Update Claim to remove Composite Reference and finalizers
+
 		xrc := claim.New()
 		xrc.SetGroupVersionKind(ref.GroupVersionKind())
 		xrc.Unstructured = *u.DeepCopy()
-		xrc.SetResourceReference(nil)
-		xrc.SetFinalizers([]string{})
+		xrc.SetFinalizers(finalizers)
+		xrc.SetResourceReference(resourceRef)
 
 		_, updateErr := res.Update(ctx, &xrc.Unstructured, metav1.UpdateOptions{})
 		return updateErr
+	})
+}
 
+// ComputeSourceClaimCleanup fetches the source Claim referred to by ref and
+// returns it alongside a copy with its resourceRef and finalizers cleared. It
+// performs no mutating calls, so it is safe to use to preview a migration.
+func ComputeSourceClaimCleanup(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference) (before, after *claim.Unstructured, err error) {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, getErr := client.Resource(gvr).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if getErr != nil {
+		return nil, nil, getErr
+	}
+
+	before = claim.New()
+	before.SetGroupVersionKind(ref.GroupVersionKind())
+	before.Unstructured = *u.DeepCopy()
+
+	after = claim.New()
+	after.SetGroupVersionKind(ref.GroupVersionKind())
+	after.Unstructured = *u.DeepCopy()
+	after.SetResourceReference(nil)
+	after.SetFinalizers([]string{})
+
+	return before, after, nil
+}
+
+// DeleteSourceClaim removes references to the Composite before deleting
+func DeleteSourceClaim(ctx context.Context, client dynamic.Interface, rmapper meta.RESTMapper, ref *v1.ObjectReference) error {
+	gvr, err := GVRFor(rmapper, ref.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	res := client.Resource(gvr).Namespace(ref.Namespace)
+
+	// RetryOnConflict uses exponential backoff to avoid exhausting the apiserver
+	retryUpdateErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, after, computeErr := ComputeSourceClaimCleanup(ctx, client, rmapper, ref)
+		if computeErr != nil {
+			return computeErr
+		}
+
+		_, updateErr := res.Update(ctx, &after.Unstructured, metav1.UpdateOptions{})
+		return updateErr
 	})
 	if retryUpdateErr != nil {
 		return retryUpdateErr
@@ -0,0 +1,52 @@
+package resource
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// newTestMapper builds a RESTMapper that maps gvk to the given plural
+// resource, so tests can exercise kinds whose plural isn't `kind.lower()+"s"`.
+func newTestMapper(gvk schema.GroupVersionKind, plural string) meta.RESTMapper {
+	m := meta.NewDefaultRESTMapper([]schema.GroupVersion{gvk.GroupVersion()})
+	m.AddSpecific(gvk, gvk.GroupVersion().WithResource(plural), gvk.GroupVersion().WithResource(plural), meta.RESTScopeNamespace)
+	return m
+}
+
+func TestGVRForIrregularPlurals(t *testing.T) {
+	cases := map[string]struct {
+		kind   string
+		plural string
+	}{
+		"Policy":   {kind: "Policy", plural: "policies"},
+		"Ingress":  {kind: "Ingress", plural: "ingresses"},
+		"Analysis": {kind: "Analysis", plural: "analyses"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gvk := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: tc.kind}
+			rmapper := newTestMapper(gvk, tc.plural)
+
+			gvr, err := GVRFor(rmapper, gvk)
+			if err != nil {
+				t.Fatalf("GVRFor(...): unexpected error: %v", err)
+			}
+			if gvr.Resource != tc.plural {
+				t.Errorf("GVRFor(...): got resource %q, want %q", gvr.Resource, tc.plural)
+			}
+		})
+	}
+}
+
+func TestGVRForUnknownKind(t *testing.T) {
+	known := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "Policy"}
+	rmapper := newTestMapper(known, "policies")
+
+	unknown := schema.GroupVersionKind{Group: "example.org", Version: "v1", Kind: "DoesNotExist"}
+	if _, err := GVRFor(rmapper, unknown); err == nil {
+		t.Errorf("GVRFor(...): expected an error for an unmapped kind, got nil")
+	}
+}